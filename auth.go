@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User is a registered mailping account. Tracking rows carry an OwnerID
+// referencing User.ID so stats stay private to the person who created them.
+type User struct {
+	ID              string    `json:"id"`
+	Email           string    `json:"email"`
+	PasswordHash    string    `json:"-"`
+	Activated       bool      `json:"activated"`
+	ActivationToken string    `json:"-"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// APIToken lets a user create tracking pixels programmatically without a
+// browser session, via an "Authorization: Bearer <token>" header.
+type APIToken struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var errInvalidCredentials = errors.New("invalid email or password")
+
+const sessionUserIDKey = "user_id"
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func registerPageHandler(c *gin.Context) {
+	c.HTML(http.StatusOK, "register.html", gin.H{"title": "Create an account"})
+}
+
+func loginPageHandler(c *gin.Context) {
+	c.HTML(http.StatusOK, "login.html", gin.H{"title": "Log in"})
+}
+
+func registerHandler(c *gin.Context) {
+	email := strings.ToLower(strings.TrimSpace(c.PostForm("email")))
+	password := c.PostForm("password")
+
+	if email == "" || password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email and password are required"})
+		return
+	}
+
+	if _, err := mail.ParseAddress(email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Enter a valid email address"})
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		logger.Errorw("bcrypt error", "error", err)
+		return
+	}
+
+	activationToken, err := generateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		logger.Errorw("Token generation error", "error", err)
+		return
+	}
+
+	user := User{
+		ID:              uuid.New().String(),
+		Email:           email,
+		PasswordHash:    string(passwordHash),
+		ActivationToken: activationToken,
+		CreatedAt:       time.Now(),
+	}
+
+	if err := store.CreateUser(user); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account with that email already exists"})
+		return
+	}
+
+	activationURL := fmt.Sprintf("%s/activate/%s", requestBaseURL(c), activationToken)
+	if err := sendEmail(user.Email, "Activate your mailping account", "Activate your account: "+activationURL); err != nil {
+		logger.Errorw("Failed to send activation email", "email", user.Email, "error", err)
+	}
+
+	c.HTML(http.StatusOK, "registered.html", gin.H{
+		"title": "Check your email",
+		"email": email,
+	})
+}
+
+func activateHandler(c *gin.Context) {
+	token := c.Param("token")
+
+	if err := store.ActivateUser(token); err != nil {
+		c.HTML(http.StatusNotFound, "activate.html", gin.H{
+			"title": "Activation failed",
+			"error": "That activation link is invalid or has already been used.",
+		})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/login")
+}
+
+func loginHandler(c *gin.Context) {
+	email := strings.ToLower(strings.TrimSpace(c.PostForm("email")))
+	password := c.PostForm("password")
+
+	user, err := authenticate(email, password)
+	if err != nil {
+		c.HTML(http.StatusUnauthorized, "login.html", gin.H{
+			"title": "Log in",
+			"error": "Invalid email or password.",
+		})
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set(sessionUserIDKey, user.ID)
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		logger.Errorw("Session save error", "error", err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/dashboard")
+}
+
+func logoutHandler(c *gin.Context) {
+	session := sessions.Default(c)
+	session.Delete(sessionUserIDKey)
+	_ = session.Save()
+	c.Redirect(http.StatusFound, "/login")
+}
+
+func authenticate(email, password string) (User, error) {
+	user, err := store.GetUserByEmail(email)
+	if err != nil {
+		return User{}, errInvalidCredentials
+	}
+
+	if !user.Activated {
+		return User{}, errInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return User{}, errInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// currentUser returns the user associated with this request's session
+// cookie or API token, or nil if the request is unauthenticated.
+func currentUser(c *gin.Context) *User {
+	session := sessions.Default(c)
+	if userID, ok := session.Get(sessionUserIDKey).(string); ok && userID != "" {
+		user, err := store.GetUserByID(userID)
+		if err == nil {
+			return &user
+		}
+	}
+
+	auth := c.GetHeader("Authorization")
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		user, err := store.GetUserByAPIToken(token)
+		if err == nil {
+			return &user
+		}
+	}
+
+	return nil
+}
+
+// requireWebAuth gates HTML pages, redirecting anonymous visitors to /login.
+func requireWebAuth(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/login")
+		c.Abort()
+		return
+	}
+
+	c.Set("user", user)
+	c.Next()
+}
+
+// requireAPIAuth gates API endpoints (session cookie or API token),
+// responding with JSON 401 instead of a redirect.
+func requireAPIAuth(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		c.Abort()
+		return
+	}
+
+	c.Set("user", user)
+	c.Next()
+}
+
+func mustCurrentUser(c *gin.Context) *User {
+	return c.MustGet("user").(*User)
+}
+
+func dashboardHandler(c *gin.Context) {
+	user := mustCurrentUser(c)
+
+	trackings, err := store.ListTrackings(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		logger.Errorw("Database error", "user_id", user.ID, "error", err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "dashboard.html", gin.H{
+		"title":     "Dashboard",
+		"email":     user.Email,
+		"trackings": trackings,
+	})
+}
+
+func createAPITokenHandler(c *gin.Context) {
+	user := mustCurrentUser(c)
+
+	token, err := generateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		logger.Errorw("Token generation error", "error", err)
+		return
+	}
+
+	apiToken := APIToken{Token: token, UserID: user.ID, CreatedAt: time.Now()}
+	if err := store.CreateAPIToken(apiToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		logger.Errorw("Database error", "user_id", user.ID, "error", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, apiToken)
+}
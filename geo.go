@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net"
+	"os"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoLookup resolves a client IP to an ISO country code.
+type GeoLookup interface {
+	Country(ip string) string
+}
+
+type noopGeoLookup struct{}
+
+func (noopGeoLookup) Country(string) string { return "" }
+
+// geo is the process-wide IP-to-country resolver. It defaults to a no-op
+// so mailping runs fine without a GeoIP database configured.
+var geo GeoLookup = noopGeoLookup{}
+
+// initGeoLookup loads a MaxMind GeoLite2 database when GEOIP_DB_PATH is set.
+func initGeoLookup() {
+	path := os.Getenv("GEOIP_DB_PATH")
+	if path == "" {
+		return
+	}
+
+	lookup, err := newMaxMindLookup(path)
+	if err != nil {
+		logger.Errorw("Failed to load GeoIP database", "path", path, "error", err)
+		return
+	}
+
+	geo = lookup
+}
+
+type maxMindLookup struct {
+	db *geoip2.Reader
+}
+
+func newMaxMindLookup(path string) (*maxMindLookup, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &maxMindLookup{db: db}, nil
+}
+
+func (m *maxMindLookup) Country(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	record, err := m.db.Country(parsed)
+	if err != nil {
+		return ""
+	}
+
+	return record.Country.IsoCode
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// LinkEvent stores information about a single tracked-link click
+type LinkEvent struct {
+	TrackingID string    `json:"tracking_id"`
+	TargetURL  string    `json:"target_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Referrer   string    `json:"referrer,omitempty"`
+}
+
+// buildLinkURL stores targetURL under a new link ID tied to trackingID and
+// wraps it in a tracking redirect. Exposed to HTML templates as the "Track"
+// func so a pixel and one or more wrapped links can be pasted into the same
+// email, e.g.
+//
+//	{{ Track .baseURL .trackingID "https://example.com" }}
+//
+// linkHandler later looks the target URL back up by link ID rather than
+// trusting a query parameter, so the redirect can't be hijacked to send
+// visitors somewhere the tracking owner never specified.
+func buildLinkURL(baseURL, trackingID, targetURL string) string {
+	linkID := uuid.New().String()
+	if err := store.CreateLink(linkID, trackingID, targetURL, time.Now()); err != nil {
+		logger.Errorw("Failed to create tracked link", "tracking_id", trackingID, "error", err)
+		return targetURL
+	}
+	return fmt.Sprintf("%s/link/%s", baseURL, linkID)
+}
+
+func createLinkHandler(c *gin.Context) {
+	trackingID := c.PostForm("tracking_id")
+	targetURL := c.PostForm("url")
+
+	if trackingID == "" || targetURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tracking_id and url are required"})
+		return
+	}
+
+	data, err := store.GetTracking(trackingID, false)
+	if err != nil || data.OwnerID != mustCurrentUser(c).ID {
+		if err != nil && !isNotFound(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			logger.Errorw("Database error", "tracking_id", trackingID, "error", err)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tracking ID not found"})
+		return
+	}
+
+	baseURL := requestBaseURL(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"tracking_id": trackingID,
+		"target_url":  targetURL,
+		"link_url":    buildLinkURL(baseURL, trackingID, targetURL),
+	})
+}
+
+func linkHandler(c *gin.Context) {
+	linkID := c.Param("id")
+	if linkID == "" {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	trackingID, targetURL, err := store.GetLink(linkID)
+	if err != nil {
+		if !isNotFound(err) {
+			logger.Errorw("Failed to look up tracked link", "link_id", linkID, "error", err)
+		}
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if err := store.RecordClick(trackingID, targetURL, c.ClientIP(), c.Request.UserAgent(), c.Request.Referer(), time.Now()); err != nil {
+		logger.Errorw("Failed to record link click", "tracking_id", trackingID, "error", err)
+	}
+
+	c.Redirect(http.StatusFound, targetURL)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// logger is the process-wide structured logger, initialized in initLogger so
+// every package can log with consistent fields instead of the stdlib "log"
+// package's unstructured text.
+var logger *zap.SugaredLogger
+
+// initLogger sets up logger for the configured environment: human-readable
+// in development, JSON in production so it's easy to ship to a log
+// aggregator.
+func initLogger() {
+	var z *zap.Logger
+	var err error
+	if config.Environment == "production" {
+		z, err = zap.NewProduction()
+	} else {
+		z, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		// zap itself failed to build; fall back to a no-op rather than a
+		// stdlib logger so every call site can keep using the sugared API.
+		z = zap.NewNop()
+	}
+
+	logger = z.Sugar()
+}
+
+// requestLogger replaces gin.Logger() with a structured, per-request log
+// line carrying the fields operators need to correlate a slow or failing
+// request with the pixel/link it belongs to: request_id, tracking_id,
+// latency, and remote_ip.
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		c.Set("request_id", requestID)
+
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		logger.Infow("request",
+			"request_id", requestID,
+			"tracking_id", c.Param("id"),
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", latency,
+			"remote_ip", c.ClientIP(),
+		)
+	}
+}
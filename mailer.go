@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// sendEmail delivers a plain-text email via the SMTP server configured
+// with SMTP_HOST (and optional SMTP_PORT/SMTP_USER/SMTP_PASS), using FROM
+// as the sender address. It's a no-op (returning an error) when SMTP_HOST
+// isn't set, so local development doesn't need a mail server.
+func sendEmail(to, subject, body string) error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return fmt.Errorf("SMTP_HOST is not configured")
+	}
+
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address %q: %w", to, err)
+	}
+	if strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("subject must not contain newlines")
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	from := os.Getenv("FROM")
+	if from == "" {
+		from = "mailping@localhost"
+	}
+
+	smtpAddr := fmt.Sprintf("%s:%s", host, port)
+	msg := []byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", from, addr.Address, subject, body))
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USER"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASS"), host)
+	}
+
+	return smtp.SendMail(smtpAddr, auth, from, []string{addr.Address}, msg)
+}
+
+// sendMailgunEmail sends an email through the Mailgun HTTP API, for
+// deployments that prefer it over plain SMTP.
+func sendMailgunEmail(domain, apiKey, to, subject, body string) error {
+	from := os.Getenv("FROM")
+	if from == "" {
+		from = "mailping@" + domain
+	}
+
+	form := url.Values{
+		"from":    {from},
+		"to":      {to},
+		"subject": {subject},
+		"text":    {body},
+	}
+
+	endpoint := fmt.Sprintf("https://api.mailgun.net/v3/%s/messages", domain)
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("api", apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("mailgun returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
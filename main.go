@@ -1,28 +1,42 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 )
 
 // TrackingData stores information about email tracking
 type TrackingData struct {
-	ID        string     `json:"id"`
-	Email     string     `json:"email"`
-	Subject   string     `json:"subject"`
-	CreatedAt time.Time  `json:"created_at"`
-	OpenedAt  *time.Time `json:"opened_at,omitempty"`
-	IPAddress string     `json:"ip_address,omitempty"`
-	UserAgent string     `json:"user_agent,omitempty"`
+	ID        string      `json:"id"`
+	Email     string      `json:"email"`
+	Subject   string      `json:"subject"`
+	CreatedAt time.Time   `json:"created_at"`
+	OpenedAt  *time.Time  `json:"opened_at,omitempty"`
+	IPAddress string      `json:"ip_address,omitempty"`
+	UserAgent string      `json:"user_agent,omitempty"`
+	Clicks    []LinkEvent `json:"clicks,omitempty"`
+	OpenStats *OpenStats  `json:"open_stats,omitempty"`
+	OwnerID   string      `json:"-"`
+
+	// NotifyWebhookURL and NotifyEmail configure the notifications (see
+	// notify.go) sent when this pixel records an open. NotifyWebhookURL is
+	// user-supplied at pixel creation and is checked by validateWebhookURL
+	// (see notify.go) to keep the notification worker from being used to
+	// reach loopback/private/link-local addresses, but that check can't
+	// account for a hostname that resolves differently by delivery time.
+	NotifyWebhookURL string `json:"-"`
+	NotifyEmail      bool   `json:"-"`
 }
 
 // Config holds application configuration
@@ -33,28 +47,51 @@ type Config struct {
 }
 
 var (
-	db     *sql.DB
+	store  Store
 	config Config
 )
 
+// errNotFound is the Store-agnostic "no such record" error (tracking ID,
+// link, user, activation token or API token); every backend maps its own
+// not-found error onto this one.
+var errNotFound = errors.New("tracking record not found")
+
+func isNotFound(err error) bool {
+	return errors.Is(err, errNotFound)
+}
+
 func main() {
 	// Initialize configuration
 	initConfig()
 
-	// Initialize database
-	initDB()
-	defer db.Close()
+	// Initialize structured logging
+	initLogger()
+	defer logger.Sync()
+
+	// Initialize GeoIP lookups (no-op unless GEOIP_DB_PATH is set)
+	initGeoLookup()
+
+	// Initialize storage backend (Postgres or SQLite, based on DATABASE_URL)
+	var err error
+	store, err = NewStore(config.DatabaseURL)
+	if err != nil {
+		logger.Fatalw("Failed to initialize store", "error", err)
+	}
+
+	// Deliver webhook/email notifications in the background so the pixel
+	// response (pixelHandler) stays fast
+	startNotificationWorker(10 * time.Second)
 
-	// Create database tables
-	createTables()
+	// Publish DB connection pool stats as Prometheus gauges
+	startDBStatsCollector(10 * time.Second)
 
 	// Set up Gin router
 	router := setupRouter()
 
 	// Start server
 	port := config.Port
-	log.Printf("Server starting in %s mode on port %s", config.Environment, port)
-	log.Fatal(router.Run(":" + port))
+	logger.Infow("Server starting", "environment", config.Environment, "port", port)
+	logger.Fatal(router.Run(":" + port))
 }
 
 func initConfig() {
@@ -87,70 +124,93 @@ func initConfig() {
 	}
 }
 
-func initDB() {
-	var err error
-	db, err = sql.Open("postgres", config.DatabaseURL)
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-
-	// Test database connection
-	if err = db.Ping(); err != nil {
-		log.Fatal("Failed to ping database:", err)
-	}
-
-	log.Println("Connected to database successfully")
-}
-
-func createTables() {
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS tracking (
-		id TEXT PRIMARY KEY,
-		email TEXT NOT NULL,
-		subject TEXT NOT NULL,
-		created_at TIMESTAMP NOT NULL,
-		opened_at TIMESTAMP,
-		ip_address TEXT,
-		user_agent TEXT
-	);`
-
-	_, err := db.Exec(createTableSQL)
-	if err != nil {
-		log.Fatal("Failed to create tables:", err)
-	}
-}
-
 func setupRouter() *gin.Engine {
-	router := gin.Default()
+	router := gin.New()
 
-	// Middleware for request logging
-	router.Use(gin.Logger())
+	// Structured, per-request logging (replaces gin.Logger()) and Prometheus
+	// handler-latency histograms
+	router.Use(requestLogger())
+	router.Use(metricsMiddleware())
 
 	// Recovery middleware to handle panics
 	router.Use(gin.Recovery())
 
+	// Cookie (default) or Redis-backed (REDIS_URL) sessions for logged-in users
+	router.Use(sessions.Sessions("mailping_session", newSessionStore()))
+
+	// Template helpers, e.g. {{ Track .baseURL .trackingID "https://example.com" }}
+	router.SetFuncMap(template.FuncMap{
+		"Track": buildLinkURL,
+	})
+
 	// Load HTML templates
 	router.LoadHTMLGlob("templates/*")
 
 	// Static files
 	router.Static("/static", "./static")
 
+	// Prometheus scrape endpoint
+	router.GET("/metrics", metricsHandler())
+
 	// Routes
 	router.GET("/", homeHandler)
-	router.POST("/create", createTrackingPixelHandler)
 	router.GET("/pixel/:id", pixelHandler)
-	router.GET("/stats/:id", statsHandler)
-	router.GET("/api/tracking/:id", apiStatsHandler)
+	router.GET("/link/:id", linkHandler)
+
+	router.GET("/register", registerPageHandler)
+	router.POST("/register", registerHandler)
+	router.GET("/activate/:token", activateHandler)
+	router.GET("/login", loginPageHandler)
+	router.POST("/login", loginHandler)
+	router.POST("/logout", logoutHandler)
+
+	router.POST("/create", requireAPIAuth, createTrackingPixelHandler)
+	router.POST("/create/link", requireAPIAuth, createLinkHandler)
+	router.GET("/api/tracking/:id", requireAPIAuth, apiStatsHandler)
+	router.POST("/api/tokens", requireAPIAuth, createAPITokenHandler)
+
+	router.GET("/stats/:id", requireWebAuth, statsHandler)
+	router.GET("/dashboard", requireWebAuth, dashboardHandler)
 
 	return router
 }
 
+// newSessionStore builds the sessions.Store backing logged-in sessions: a
+// signed cookie by default, or Redis when REDIS_URL is set so sessions
+// survive across multiple mailping instances.
+func newSessionStore() sessions.Store {
+	secret := os.Getenv("SESSION_SECRET")
+	if secret == "" {
+		secret = "dev-secret-change-me"
+	}
+
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		store, err := redis.NewStore(10, "tcp", redisURL, "", "", []byte(secret))
+		if err != nil {
+			logger.Fatalw("Failed to connect to session store", "error", err)
+		}
+		return store
+	}
+
+	return cookie.NewStore([]byte(secret))
+}
+
 func homeHandler(c *gin.Context) {
 	c.HTML(http.StatusOK, "home.html", gin.H{
 		"title": "Email Tracker",
 	})
 }
 
+// requestBaseURL derives the externally-visible base URL for this request,
+// honoring a reverse proxy's X-Forwarded-Proto.
+func requestBaseURL(c *gin.Context) string {
+	protocol := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		protocol = "https"
+	}
+	return fmt.Sprintf("%s://%s", protocol, c.Request.Host)
+}
+
 func createTrackingPixelHandler(c *gin.Context) {
 	email := c.PostForm("email")
 	subject := c.PostForm("subject")
@@ -160,27 +220,38 @@ func createTrackingPixelHandler(c *gin.Context) {
 		return
 	}
 
+	owner := mustCurrentUser(c)
+
+	webhookURL := c.PostForm("webhook_url")
+	if webhookURL != "" {
+		if err := validateWebhookURL(webhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Generate unique ID for tracking
 	trackingID := uuid.New().String()
 	createdAt := time.Now()
 
-	// Store tracking data in database
-	_, err := db.Exec(
-		"INSERT INTO tracking (id, email, subject, created_at) VALUES ($1, $2, $3, $4)",
-		trackingID, email, subject, createdAt,
-	)
+	// Store tracking data, along with how the owner wants to be notified of opens
+	err := store.CreateTracking(TrackingData{
+		ID:               trackingID,
+		Email:            email,
+		Subject:          subject,
+		CreatedAt:        createdAt,
+		OwnerID:          owner.ID,
+		NotifyWebhookURL: webhookURL,
+		NotifyEmail:      c.PostForm("notify_email") == "true",
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tracking record"})
-		log.Println("Database error:", err)
+		logger.Errorw("Failed to create tracking record", "error", err)
 		return
 	}
+	pixelsCreatedTotal.Inc()
 
-	// Get base URL (consider protocol and host)
-	protocol := "http"
-	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
-		protocol = "https"
-	}
-	baseURL := fmt.Sprintf("%s://%s", protocol, c.Request.Host)
+	baseURL := requestBaseURL(c)
 	pixelURL := fmt.Sprintf("%s/pixel/%s", baseURL, trackingID)
 	htmlCode := fmt.Sprintf(`<img src="%s" width="1" height="1" alt="" />`, pixelURL)
 	statsURL := fmt.Sprintf("%s/stats/%s", baseURL, trackingID)
@@ -208,13 +279,11 @@ func pixelHandler(c *gin.Context) {
 	userAgent := c.Request.UserAgent()
 
 	// Log the email open event
-	now := time.Now()
-	_, err := db.Exec(
-		"UPDATE tracking SET opened_at = $1, ip_address = $2, user_agent = $3 WHERE id = $4 AND opened_at IS NULL",
-		now, ipAddress, userAgent, trackingID,
-	)
+	firstOpen, err := store.RecordOpen(trackingID, ipAddress, userAgent, c.Request.Referer(), time.Now())
 	if err != nil {
-		log.Println("Failed to update tracking record:", err)
+		logger.Errorw("Failed to record open event", "tracking_id", trackingID, "error", err)
+	} else {
+		recordOpenMetric(firstOpen, userAgent)
 	}
 
 	// Return a transparent 1x1 pixel
@@ -228,15 +297,17 @@ func statsHandler(c *gin.Context) {
 		return
 	}
 
+	realOnly := c.Query("real_only") == "true"
+
 	// Get tracking data
-	data, err := getTrackingData(trackingID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.Status(http.StatusNotFound)
-		} else {
+	data, err := store.GetTracking(trackingID, realOnly)
+	if err != nil || data.OwnerID != mustCurrentUser(c).ID {
+		if err != nil && !isNotFound(err) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			log.Println("Database error:", err)
+			logger.Errorw("Database error", "tracking_id", trackingID, "error", err)
+			return
 		}
+		c.Status(http.StatusNotFound)
 		return
 	}
 
@@ -253,6 +324,7 @@ func statsHandler(c *gin.Context) {
 		"data":        data,
 		"statusClass": statusClass,
 		"status":      status,
+		"realOnly":    realOnly,
 	})
 }
 
@@ -263,41 +335,23 @@ func apiStatsHandler(c *gin.Context) {
 		return
 	}
 
+	realOnly := c.Query("real_only") == "true"
+
 	// Get tracking data
-	data, err := getTrackingData(trackingID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Tracking ID not found"})
-		} else {
+	data, err := store.GetTracking(trackingID, realOnly)
+	if err != nil || data.OwnerID != mustCurrentUser(c).ID {
+		if err != nil && !isNotFound(err) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			log.Println("Database error:", err)
+			logger.Errorw("Database error", "tracking_id", trackingID, "error", err)
+			return
 		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tracking ID not found"})
 		return
 	}
 
 	c.JSON(http.StatusOK, data)
 }
 
-func getTrackingData(trackingID string) (TrackingData, error) {
-	var data TrackingData
-	var openedAt sql.NullTime
-
-	err := db.QueryRow(
-		"SELECT id, email, subject, created_at, opened_at, ip_address, user_agent FROM tracking WHERE id = $1",
-		trackingID,
-	).Scan(&data.ID, &data.Email, &data.Subject, &data.CreatedAt, &openedAt, &data.IPAddress, &data.UserAgent)
-
-	if err != nil {
-		return data, err
-	}
-
-	if openedAt.Valid {
-		data.OpenedAt = &openedAt.Time
-	}
-
-	return data, nil
-}
-
 // Helper function to generate a transparent 1x1 GIF pixel
 func transparentPixel() []byte {
 	// This is a raw representation of a transparent 1x1 GIF
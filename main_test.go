@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	logger = zap.NewNop().Sugar()
+}
+
+// withUser stubs requireAPIAuth/requireWebAuth for handler tests that need
+// mustCurrentUser without exercising sessions or API tokens.
+func withUser(user *User) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+func TestPixelHandlerRecordsOpen(t *testing.T) {
+	store = newMemoryStore()
+
+	trackingID := "tracking-1"
+	if err := store.CreateTracking(TrackingData{ID: trackingID, Email: "a@example.com", Subject: "hi", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateTracking: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/pixel/:id", pixelHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/pixel/"+trackingID, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/gif" {
+		t.Fatalf("Content-Type = %q, want image/gif", ct)
+	}
+
+	data, err := store.GetTracking(trackingID, false)
+	if err != nil {
+		t.Fatalf("GetTracking: %v", err)
+	}
+	if data.OpenStats == nil || data.OpenStats.OpenCount != 1 {
+		t.Fatalf("OpenStats = %+v, want OpenCount 1", data.OpenStats)
+	}
+}
+
+func TestLinkHandlerRedirectsToStoredTarget(t *testing.T) {
+	store = newMemoryStore()
+
+	trackingID := "tracking-1"
+	targetURL := "https://example.com/real-target"
+	if err := store.CreateLink("link-1", trackingID, targetURL, time.Now()); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/link/:id", linkHandler)
+
+	// An attacker-supplied url query param must be ignored: the redirect
+	// target comes only from what was stored at link-creation time.
+	req := httptest.NewRequest(http.MethodGet, "/link/link-1?url=https://evil.example", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	if loc := rec.Header().Get("Location"); loc != targetURL {
+		t.Fatalf("Location = %q, want %q", loc, targetURL)
+	}
+
+	clicks := store.(*memoryStore).clicks[trackingID]
+	if len(clicks) != 1 || clicks[0].TargetURL != targetURL {
+		t.Fatalf("clicks = %+v, want one click to %q", clicks, targetURL)
+	}
+}
+
+func TestLinkHandlerUnknownIDNotFound(t *testing.T) {
+	store = newMemoryStore()
+
+	router := gin.New()
+	router.GET("/link/:id", linkHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/link/does-not-exist?url=https://evil.example", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if loc := rec.Header().Get("Location"); loc != "" {
+		t.Fatalf("Location = %q, want no redirect", loc)
+	}
+}
+
+func TestAPIStatsHandlerReturnsOwnerData(t *testing.T) {
+	store = newMemoryStore()
+
+	owner := &User{ID: "owner-1", Email: "owner@example.com"}
+	trackingID := "tracking-1"
+	if err := store.CreateTracking(TrackingData{ID: trackingID, OwnerID: owner.ID, Email: "a@example.com", Subject: "hi", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateTracking: %v", err)
+	}
+	if err := store.RecordClick(trackingID, "https://example.com", "1.2.3.4", "ua", "", time.Now()); err != nil {
+		t.Fatalf("RecordClick: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/api/tracking/:id", withUser(owner), apiStatsHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tracking/"+trackingID, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body = %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+}
+
+func TestAPIStatsHandlerHidesOtherOwnersData(t *testing.T) {
+	store = newMemoryStore()
+
+	trackingID := "tracking-1"
+	if err := store.CreateTracking(TrackingData{ID: trackingID, OwnerID: "owner-1", Email: "a@example.com", Subject: "hi", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateTracking: %v", err)
+	}
+
+	intruder := &User{ID: "owner-2", Email: "intruder@example.com"}
+
+	router := gin.New()
+	router.GET("/api/tracking/:id", withUser(intruder), apiStatsHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tracking/"+trackingID, nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
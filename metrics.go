@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	pixelsCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "mailping_pixels_created_total",
+		Help: "Total number of tracking pixels created.",
+	})
+
+	// opensTotal is labeled "kind" (first or repeat) and "ua_class" (human
+	// or prefetch) so operators can alert on drop-offs or proxy abuse.
+	opensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mailping_opens_total",
+		Help: "Total number of pixel opens recorded, by open kind and user-agent class.",
+	}, []string{"kind", "ua_class"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mailping_http_request_duration_seconds",
+		Help:    "HTTP handler latency in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path", "status"})
+
+	dbOpenConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mailping_db_open_connections",
+		Help: "Number of established connections to the database, in use or idle.",
+	})
+	dbInUseConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mailping_db_in_use_connections",
+		Help: "Number of database connections currently in use.",
+	})
+	dbIdleConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mailping_db_idle_connections",
+		Help: "Number of idle database connections.",
+	})
+)
+
+// recordOpenMetric labels an open by whether it was the pixel's first open
+// or a repeat, and whether the user agent looks like a real reader or a
+// prefetching bot/proxy.
+func recordOpenMetric(firstOpen bool, userAgent string) {
+	kind := "repeat"
+	if firstOpen {
+		kind = "first"
+	}
+
+	uaClass := "human"
+	if isPrefetchUA(userAgent) {
+		uaClass = "prefetch"
+	}
+
+	opensTotal.WithLabelValues(kind, uaClass).Inc()
+}
+
+// metricsMiddleware records handler latency, labeled by route so a single
+// slow tracking ID doesn't blow up cardinality.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		httpRequestDuration.WithLabelValues(
+			c.Request.Method,
+			c.FullPath(),
+			strconv.Itoa(c.Writer.Status()),
+		).Observe(time.Since(start).Seconds())
+	}
+}
+
+// startDBStatsCollector periodically publishes the store's connection pool
+// stats as gauges, since prometheus has no push-based gauge for this.
+func startDBStatsCollector(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			stats := store.DBStats()
+			dbOpenConnections.Set(float64(stats.OpenConnections))
+			dbInUseConnections.Set(float64(stats.InUse))
+			dbIdleConnections.Set(float64(stats.Idle))
+		}
+	}()
+}
+
+// metricsHandler exposes /metrics for Prometheus to scrape.
+func metricsHandler() gin.HandlerFunc {
+	h := promhttp.Handler()
+	return gin.WrapH(h)
+}
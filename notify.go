@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Notification is a queued webhook or email delivery for a recorded open,
+// processed by the background worker started in main so pixelHandler's
+// response stays fast.
+type Notification struct {
+	ID            int64
+	TrackingID    string
+	Kind          string // "webhook" or "email"
+	Target        string // webhook URL, or the owner's email address
+	Payload       []byte
+	Attempts      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+// notificationPayload is the JSON body delivered to webhooks and used to
+// compose notification emails.
+type notificationPayload struct {
+	Event     string       `json:"event"`
+	Tracking  TrackingData `json:"tracking"`
+	Open      OpenEvent    `json:"open"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+const maxNotificationAttempts = 5
+
+// webhookTimeout bounds how long a single slow or unresponsive webhook
+// target can hold up the notification worker, which delivers its batch
+// serially on one goroutine.
+const webhookTimeout = 10 * time.Second
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// startNotificationWorker polls for due notifications and delivers them on
+// a fixed interval, retrying failures with backoff.
+func startNotificationWorker(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			deliverPendingNotifications()
+		}
+	}()
+}
+
+func deliverPendingNotifications() {
+	notifications, err := store.DuePendingNotifications(time.Now())
+	if err != nil {
+		logger.Errorw("Failed to load pending notifications", "error", err)
+		return
+	}
+
+	for _, n := range notifications {
+		deliverNotification(n)
+	}
+}
+
+func deliverNotification(n Notification) {
+	var err error
+	switch n.Kind {
+	case "webhook":
+		err = deliverWebhook(n)
+	case "email":
+		err = deliverEmailNotification(n)
+	default:
+		err = fmt.Errorf("unknown notification kind %q", n.Kind)
+	}
+
+	if err != nil {
+		attempts := n.Attempts + 1
+		logger.Errorw("Notification delivery failed", "notification_id", n.ID, "kind", n.Kind, "attempt", attempts, "error", err)
+		if markErr := store.MarkNotificationFailed(n.ID, attempts, nextAttemptBackoff(attempts)); markErr != nil {
+			logger.Errorw("Failed to record notification failure", "notification_id", n.ID, "error", markErr)
+		}
+		return
+	}
+
+	if err := store.MarkNotificationSent(n.ID); err != nil {
+		logger.Errorw("Failed to mark notification sent", "notification_id", n.ID, "error", err)
+	}
+}
+
+// nextAttemptBackoff grows quadratically with the attempt count, capped at
+// an hour, so a flaky endpoint doesn't get hammered.
+func nextAttemptBackoff(attempt int) time.Time {
+	delay := time.Duration(attempt*attempt) * time.Minute
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return time.Now().Add(delay)
+}
+
+var errWebhookURLNotAllowed = errors.New("webhook_url must be a public http(s) address")
+
+// validateWebhookURL rejects webhook URLs that would let any registered
+// user make the notification worker issue requests against internal
+// infrastructure (cloud metadata endpoints, redis/postgres on localhost,
+// RFC1918 ranges, etc). It's checked once at pixel-creation time in
+// createTrackingPixelHandler; a DNS name that resolves differently by the
+// time the worker delivers the notification isn't caught here, but this
+// stops the common case of someone just pointing webhook_url at an
+// internal address.
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook_url: %w", err)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errWebhookURLNotAllowed
+	}
+
+	host := parsed.Hostname()
+	if host == "" {
+		return errWebhookURLNotAllowed
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("could not resolve webhook_url host: %w", err)
+	}
+
+	for _, ip := range ips {
+		if isDisallowedWebhookIP(ip) {
+			return errWebhookURLNotAllowed
+		}
+	}
+
+	return nil
+}
+
+// isDisallowedWebhookIP reports whether ip falls in a range that has no
+// business receiving a webhook from this service: loopback, link-local
+// (including the 169.254.169.254 cloud metadata address), private,
+// unspecified, or multicast.
+func isDisallowedWebhookIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsMulticast()
+}
+
+func deliverWebhook(n Notification) error {
+	req, err := http.NewRequest(http.MethodPost, n.Target, bytes.NewReader(n.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Mailping-Signature", signPayload(n.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// signPayload HMAC-SHA256-signs a webhook body with WEBHOOK_SECRET so
+// receivers can verify the X-Mailping-Signature header.
+func signPayload(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("WEBHOOK_SECRET")))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func deliverEmailNotification(n Notification) error {
+	var p notificationPayload
+	if err := json.Unmarshal(n.Payload, &p); err != nil {
+		return err
+	}
+
+	// Prefer Mailgun when configured, otherwise fall back to SMTP
+	if domain := os.Getenv("MAILGUN_DOMAIN"); domain != "" {
+		return sendMailgunEmail(domain, os.Getenv("MAILGUN_KEY"), n.Target, "Your email was opened", notificationBody(p))
+	}
+
+	return sendEmail(n.Target, "Your email was opened", notificationBody(p))
+}
+
+func notificationBody(p notificationPayload) string {
+	return fmt.Sprintf(
+		"Your tracked email %q to %s was opened at %s from %s.",
+		p.Tracking.Subject, p.Tracking.Email, p.Open.CreatedAt.Format(time.RFC1123), p.Open.IPAddress,
+	)
+}
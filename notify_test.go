@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestValidateWebhookURL(t *testing.T) {
+	cases := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"public ip", "http://93.184.216.34/hook", false},
+		{"loopback", "http://127.0.0.1/hook", true},
+		{"link-local metadata", "http://169.254.169.254/latest/meta-data", true},
+		{"private range", "http://10.0.0.5:8080/hook", true},
+		{"unspecified", "http://0.0.0.0/hook", true},
+		{"non-http scheme", "ftp://93.184.216.34/hook", true},
+		{"no host", "http:///hook", true},
+		{"unparseable", "://not-a-url", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateWebhookURL(tc.url)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("validateWebhookURL(%q) error = %v, wantErr %v", tc.url, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// OpenEvent stores a single recorded pixel hit, unlike the legacy
+// tracking.opened_at column which only remembers the first one.
+type OpenEvent struct {
+	TrackingID string    `json:"tracking_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Country    string    `json:"country,omitempty"`
+	Referer    string    `json:"referer,omitempty"`
+	IsPrefetch bool      `json:"is_prefetch"`
+}
+
+// OpenStats summarizes the open_events recorded for a tracking ID.
+type OpenStats struct {
+	OpenCount     int         `json:"open_count"`
+	RealOpenCount int         `json:"real_open_count"`
+	UniqueIPs     int         `json:"unique_ips"`
+	FirstOpenAt   *time.Time  `json:"first_open_at,omitempty"`
+	LastOpenAt    *time.Time  `json:"last_open_at,omitempty"`
+	Opens         []OpenEvent `json:"opens"`
+}
+
+// prefetchUAMarkers are user-agent substrings belonging to mail clients
+// and link-preview bots that fetch images server-side (link
+// prefetching/proxying) rather than a human opening the email, so they
+// shouldn't count as "real" opens.
+var prefetchUAMarkers = []string{
+	"GoogleImageProxy",
+	"YahooMailProxy",
+	"Outlook-iOS",
+	"Slackbot-LinkExpanding",
+	"facebookexternalhit",
+}
+
+// isPrefetchUA reports whether a user agent looks like a bot/proxy that
+// prefetches images instead of a real open.
+func isPrefetchUA(userAgent string) bool {
+	for _, marker := range prefetchUAMarkers {
+		if strings.Contains(userAgent, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectOpenStats scans open_events rows (tracking_id, created_at,
+// ip_address, user_agent, country, referer, is_prefetch, in that order)
+// into an OpenStats summary. Shared by every Store implementation so the
+// aggregation logic doesn't drift between backends.
+func collectOpenStats(rows *sql.Rows, realOnly bool) (*OpenStats, error) {
+	stats := &OpenStats{}
+	uniqueIPs := make(map[string]struct{})
+
+	for rows.Next() {
+		var e OpenEvent
+		if err := rows.Scan(&e.TrackingID, &e.CreatedAt, &e.IPAddress, &e.UserAgent, &e.Country, &e.Referer, &e.IsPrefetch); err != nil {
+			return nil, err
+		}
+
+		if realOnly && e.IsPrefetch {
+			continue
+		}
+
+		stats.Opens = append(stats.Opens, e)
+		stats.OpenCount++
+		if !e.IsPrefetch {
+			stats.RealOpenCount++
+		}
+		if e.IPAddress != "" {
+			uniqueIPs[e.IPAddress] = struct{}{}
+		}
+
+		createdAt := e.CreatedAt
+		if stats.FirstOpenAt == nil {
+			stats.FirstOpenAt = &createdAt
+		}
+		stats.LastOpenAt = &createdAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats.UniqueIPs = len(uniqueIPs)
+
+	return stats, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Store is the persistence layer mailping needs: creating tracking pixels,
+// recording opens and link clicks, and reading them back for the stats
+// endpoints. DATABASE_URL's scheme selects the implementation, so mailping
+// can run against Postgres in production or SQLite for personal/local use.
+type Store interface {
+	CreateTracking(data TrackingData) error
+	// RecordOpen logs a pixel hit and reports whether it was the tracking
+	// ID's first recorded open, so callers can label metrics accordingly.
+	RecordOpen(trackingID, ipAddress, userAgent, referer string, createdAt time.Time) (firstOpen bool, err error)
+	RecordClick(trackingID, targetURL, ipAddress, userAgent, referrer string, createdAt time.Time) error
+	// CreateLink stores the target URL a wrapped tracking link points to, so
+	// linkHandler can look it up instead of trusting a client-supplied URL.
+	CreateLink(linkID, trackingID, targetURL string, createdAt time.Time) error
+	GetLink(linkID string) (trackingID, targetURL string, err error)
+	GetTracking(trackingID string, realOnly bool) (TrackingData, error)
+	ListTrackings(ownerID string) ([]TrackingData, error)
+
+	CreateUser(user User) error
+	GetUserByEmail(email string) (User, error)
+	GetUserByID(userID string) (User, error)
+	ActivateUser(activationToken string) error
+	CreateAPIToken(token APIToken) error
+	GetUserByAPIToken(token string) (User, error)
+
+	DuePendingNotifications(now time.Time) ([]Notification, error)
+	MarkNotificationSent(id int64) error
+	MarkNotificationFailed(id int64, attempts int, nextAttemptAt time.Time) error
+
+	// DBStats exposes the underlying connection pool stats so metrics.go
+	// can publish them as Prometheus gauges.
+	DBStats() sql.DBStats
+}
+
+// needsOpenNotification reports whether an open on a tracking row with the
+// given webhook URL (as scanned into a nullable column) and notify_email
+// flag should enqueue any notifications at all, so enqueueOpenNotifications
+// can skip the marshal and INSERT when the owner asked for neither.
+func needsOpenNotification(webhookURL sql.NullString, notifyEmail bool) bool {
+	return (webhookURL.Valid && webhookURL.String != "") || notifyEmail
+}
+
+// NewStore opens a Store for the given DATABASE_URL. A "sqlite://" or
+// "file:" scheme (or a bare path ending in .db/.sqlite) selects SQLite;
+// everything else is treated as a Postgres DSN.
+func NewStore(databaseURL string) (Store, error) {
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+
+	switch parsed.Scheme {
+	case "sqlite", "file":
+		return newSQLiteStore(databaseURL)
+	case "":
+		if strings.HasSuffix(parsed.Path, ".db") || strings.HasSuffix(parsed.Path, ".sqlite") {
+			return newSQLiteStore(databaseURL)
+		}
+		return newPostgresStore(databaseURL)
+	default:
+		return newPostgresStore(databaseURL)
+	}
+}
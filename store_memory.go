@@ -0,0 +1,344 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errEmailTaken = errors.New("email already registered")
+
+// memoryStore is an in-process Store backed by plain maps, with no
+// persistence and no external dependencies. It exists so handlers can be
+// exercised in tests without a Postgres or SQLite connection; it is not
+// selected by NewStore and isn't meant for production use.
+type memoryStore struct {
+	mu sync.Mutex
+
+	trackings map[string]TrackingData
+	opens     map[string][]OpenEvent
+	clicks    map[string][]LinkEvent
+	links     map[string]linkRecord
+
+	users            map[string]User
+	usersByEmail     map[string]string
+	activationTokens map[string]string
+	apiTokens        map[string]string
+
+	notifications map[int64]*Notification
+	nextNotifyID  int64
+}
+
+type linkRecord struct {
+	trackingID string
+	targetURL  string
+}
+
+// newMemoryStore returns a ready-to-use memoryStore.
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		trackings:        make(map[string]TrackingData),
+		opens:            make(map[string][]OpenEvent),
+		clicks:           make(map[string][]LinkEvent),
+		links:            make(map[string]linkRecord),
+		users:            make(map[string]User),
+		usersByEmail:     make(map[string]string),
+		activationTokens: make(map[string]string),
+		apiTokens:        make(map[string]string),
+		notifications:    make(map[int64]*Notification),
+	}
+}
+
+func (s *memoryStore) DBStats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+func (s *memoryStore) CreateTracking(data TrackingData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trackings[data.ID] = data
+	return nil
+}
+
+func (s *memoryStore) RecordOpen(trackingID, ipAddress, userAgent, referer string, createdAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracking, ok := s.trackings[trackingID]
+	firstOpen := ok && tracking.OpenedAt == nil
+	if firstOpen {
+		openedAt := createdAt
+		tracking.OpenedAt = &openedAt
+		tracking.IPAddress = ipAddress
+		tracking.UserAgent = userAgent
+		s.trackings[trackingID] = tracking
+	}
+
+	event := OpenEvent{
+		TrackingID: trackingID,
+		CreatedAt:  createdAt,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Country:    geo.Country(ipAddress),
+		Referer:    referer,
+		IsPrefetch: isPrefetchUA(userAgent),
+	}
+	s.opens[trackingID] = append(s.opens[trackingID], event)
+
+	if !ok {
+		return false, errNotFound
+	}
+
+	return firstOpen, s.enqueueOpenNotifications(tracking, event)
+}
+
+// enqueueOpenNotifications mirrors the SQL backends' guard (see
+// needsOpenNotification in store.go) so an open with no webhook or email
+// configured doesn't bother creating a notification row.
+func (s *memoryStore) enqueueOpenNotifications(tracking TrackingData, event OpenEvent) error {
+	webhookURL := sql.NullString{String: tracking.NotifyWebhookURL, Valid: true}
+	if !needsOpenNotification(webhookURL, tracking.NotifyEmail) {
+		return nil
+	}
+
+	if tracking.NotifyWebhookURL != "" {
+		s.createNotification(tracking.ID, "webhook", tracking.NotifyWebhookURL, event)
+	}
+
+	if tracking.NotifyEmail {
+		if owner, ok := s.users[tracking.OwnerID]; ok && owner.Email != "" {
+			s.createNotification(tracking.ID, "email", owner.Email, event)
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) createNotification(trackingID, kind, target string, event OpenEvent) {
+	s.nextNotifyID++
+	s.notifications[s.nextNotifyID] = &Notification{
+		ID:            s.nextNotifyID,
+		TrackingID:    trackingID,
+		Kind:          kind,
+		Target:        target,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+}
+
+func (s *memoryStore) RecordClick(trackingID, targetURL, ipAddress, userAgent, referrer string, createdAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks[trackingID] = append(s.clicks[trackingID], LinkEvent{
+		TrackingID: trackingID,
+		TargetURL:  targetURL,
+		CreatedAt:  createdAt,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Referrer:   referrer,
+	})
+	return nil
+}
+
+func (s *memoryStore) CreateLink(linkID, trackingID, targetURL string, createdAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.links[linkID] = linkRecord{trackingID: trackingID, targetURL: targetURL}
+	return nil
+}
+
+func (s *memoryStore) GetLink(linkID string) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[linkID]
+	if !ok {
+		return "", "", errNotFound
+	}
+	return link.trackingID, link.targetURL, nil
+}
+
+func (s *memoryStore) GetTracking(trackingID string, realOnly bool) (TrackingData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.trackings[trackingID]
+	if !ok {
+		return TrackingData{}, errNotFound
+	}
+
+	data.Clicks = append([]LinkEvent(nil), s.clicks[trackingID]...)
+	data.OpenStats = s.openStats(trackingID, realOnly)
+
+	return data, nil
+}
+
+func (s *memoryStore) openStats(trackingID string, realOnly bool) *OpenStats {
+	stats := &OpenStats{}
+	uniqueIPs := make(map[string]struct{})
+
+	for _, e := range s.opens[trackingID] {
+		if realOnly && e.IsPrefetch {
+			continue
+		}
+
+		stats.Opens = append(stats.Opens, e)
+		stats.OpenCount++
+		if !e.IsPrefetch {
+			stats.RealOpenCount++
+		}
+		if e.IPAddress != "" {
+			uniqueIPs[e.IPAddress] = struct{}{}
+		}
+
+		createdAt := e.CreatedAt
+		if stats.FirstOpenAt == nil {
+			stats.FirstOpenAt = &createdAt
+		}
+		stats.LastOpenAt = &createdAt
+	}
+
+	stats.UniqueIPs = len(uniqueIPs)
+
+	return stats
+}
+
+func (s *memoryStore) ListTrackings(ownerID string) ([]TrackingData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var trackings []TrackingData
+	for _, data := range s.trackings {
+		if data.OwnerID == ownerID {
+			trackings = append(trackings, data)
+		}
+	}
+
+	sort.Slice(trackings, func(i, j int) bool {
+		return trackings[i].CreatedAt.After(trackings[j].CreatedAt)
+	})
+
+	return trackings, nil
+}
+
+func (s *memoryStore) CreateUser(user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.usersByEmail[user.Email]; exists {
+		return errEmailTaken
+	}
+
+	s.users[user.ID] = user
+	s.usersByEmail[user.Email] = user.ID
+	if user.ActivationToken != "" {
+		s.activationTokens[user.ActivationToken] = user.ID
+	}
+	return nil
+}
+
+func (s *memoryStore) GetUserByEmail(email string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userID, ok := s.usersByEmail[email]
+	if !ok {
+		return User{}, errNotFound
+	}
+	return s.users[userID], nil
+}
+
+func (s *memoryStore) GetUserByID(userID string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return User{}, errNotFound
+	}
+	return user, nil
+}
+
+func (s *memoryStore) ActivateUser(activationToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userID, ok := s.activationTokens[activationToken]
+	if !ok {
+		return errNotFound
+	}
+
+	user := s.users[userID]
+	if user.Activated {
+		return errNotFound
+	}
+
+	user.Activated = true
+	user.ActivationToken = ""
+	s.users[userID] = user
+	delete(s.activationTokens, activationToken)
+
+	return nil
+}
+
+func (s *memoryStore) CreateAPIToken(token APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiTokens[token.Token] = token.UserID
+	return nil
+}
+
+func (s *memoryStore) GetUserByAPIToken(token string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userID, ok := s.apiTokens[token]
+	if !ok {
+		return User{}, errNotFound
+	}
+	return s.users[userID], nil
+}
+
+func (s *memoryStore) DuePendingNotifications(now time.Time) ([]Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Notification
+	for _, n := range s.notifications {
+		if n.NextAttemptAt.After(now) {
+			continue
+		}
+		due = append(due, *n)
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+
+	return due, nil
+}
+
+func (s *memoryStore) MarkNotificationSent(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.notifications, id)
+	return nil
+}
+
+func (s *memoryStore) MarkNotificationFailed(id int64, attempts int, nextAttemptAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n, ok := s.notifications[id]; ok {
+		n.Attempts = attempts
+		n.NextAttemptAt = nextAttemptAt
+	}
+	return nil
+}
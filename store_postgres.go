@@ -0,0 +1,438 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresStore is the production Store backend.
+type postgresStore struct {
+	db *sql.DB
+}
+
+func newPostgresStore(databaseURL string) (Store, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	s := &postgresStore{db: db}
+	if err := s.createTables(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// DBStats exposes the connection pool stats metrics.go publishes as gauges.
+func (s *postgresStore) DBStats() sql.DBStats {
+	return s.db.Stats()
+}
+
+func (s *postgresStore) createTables() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY,
+			email TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			activated BOOLEAN NOT NULL DEFAULT FALSE,
+			activation_token TEXT,
+			created_at TIMESTAMP NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			token TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS tracking (
+			id TEXT PRIMARY KEY,
+			owner_id TEXT NOT NULL DEFAULT '',
+			email TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			opened_at TIMESTAMP,
+			ip_address TEXT,
+			user_agent TEXT,
+			notify_webhook_url TEXT,
+			notify_email BOOLEAN NOT NULL DEFAULT FALSE
+		);`,
+		`CREATE TABLE IF NOT EXISTS links (
+			id TEXT PRIMARY KEY,
+			tracking_id TEXT NOT NULL,
+			target_url TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS link_events (
+			id SERIAL PRIMARY KEY,
+			tracking_id TEXT NOT NULL,
+			target_url TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			ip_address TEXT,
+			user_agent TEXT,
+			referrer TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS open_events (
+			id SERIAL PRIMARY KEY,
+			tracking_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			ip_address TEXT,
+			user_agent TEXT,
+			country TEXT,
+			referer TEXT,
+			is_prefetch BOOLEAN NOT NULL DEFAULT FALSE
+		);`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id SERIAL PRIMARY KEY,
+			tracking_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			target TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		);`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *postgresStore) CreateTracking(data TrackingData) error {
+	_, err := s.db.Exec(
+		"INSERT INTO tracking (id, owner_id, email, subject, created_at, notify_webhook_url, notify_email) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		data.ID, data.OwnerID, data.Email, data.Subject, data.CreatedAt, data.NotifyWebhookURL, data.NotifyEmail,
+	)
+	return err
+}
+
+func (s *postgresStore) RecordOpen(trackingID, ipAddress, userAgent, referer string, createdAt time.Time) (bool, error) {
+	// Keep the first-open columns on tracking for backwards compatibility
+	result, err := s.db.Exec(
+		"UPDATE tracking SET opened_at = $1, ip_address = $2, user_agent = $3 WHERE id = $4 AND opened_at IS NULL",
+		createdAt, ipAddress, userAgent, trackingID,
+	)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	firstOpen := rowsAffected > 0
+
+	event := OpenEvent{
+		TrackingID: trackingID,
+		CreatedAt:  createdAt,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Country:    geo.Country(ipAddress),
+		Referer:    referer,
+		IsPrefetch: isPrefetchUA(userAgent),
+	}
+
+	if _, err := s.db.Exec(
+		"INSERT INTO open_events (tracking_id, created_at, ip_address, user_agent, country, referer, is_prefetch) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		trackingID, event.CreatedAt, event.IPAddress, event.UserAgent, event.Country, event.Referer, event.IsPrefetch,
+	); err != nil {
+		return firstOpen, err
+	}
+
+	return firstOpen, s.enqueueOpenNotifications(trackingID, event)
+}
+
+// enqueueOpenNotifications queues webhook/email notifications for a
+// tracking pixel's owner, if they configured any at creation time.
+func (s *postgresStore) enqueueOpenNotifications(trackingID string, event OpenEvent) error {
+	var data TrackingData
+	var webhookURL sql.NullString
+	var ownerEmail sql.NullString
+
+	err := s.db.QueryRow(
+		`SELECT t.id, t.email, t.subject, t.created_at, t.notify_webhook_url, t.notify_email, u.email
+		 FROM tracking t LEFT JOIN users u ON u.id = t.owner_id WHERE t.id = $1`,
+		trackingID,
+	).Scan(&data.ID, &data.Email, &data.Subject, &data.CreatedAt, &webhookURL, &data.NotifyEmail, &ownerEmail)
+	if err != nil {
+		return err
+	}
+
+	if !needsOpenNotification(webhookURL, data.NotifyEmail) {
+		return nil
+	}
+
+	payload, err := json.Marshal(notificationPayload{Event: "open", Tracking: data, Open: event, CreatedAt: event.CreatedAt})
+	if err != nil {
+		return err
+	}
+
+	if webhookURL.Valid && webhookURL.String != "" {
+		if err := s.createNotification(trackingID, "webhook", webhookURL.String, payload); err != nil {
+			return err
+		}
+	}
+
+	if data.NotifyEmail && ownerEmail.Valid && ownerEmail.String != "" {
+		if err := s.createNotification(trackingID, "email", ownerEmail.String, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *postgresStore) createNotification(trackingID, kind, target string, payload []byte) error {
+	_, err := s.db.Exec(
+		"INSERT INTO notifications (tracking_id, kind, target, payload, next_attempt_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		trackingID, kind, target, payload, time.Now(), time.Now(),
+	)
+	return err
+}
+
+func (s *postgresStore) RecordClick(trackingID, targetURL, ipAddress, userAgent, referrer string, createdAt time.Time) error {
+	_, err := s.db.Exec(
+		"INSERT INTO link_events (tracking_id, target_url, created_at, ip_address, user_agent, referrer) VALUES ($1, $2, $3, $4, $5, $6)",
+		trackingID, targetURL, createdAt, ipAddress, userAgent, referrer,
+	)
+	return err
+}
+
+func (s *postgresStore) CreateLink(linkID, trackingID, targetURL string, createdAt time.Time) error {
+	_, err := s.db.Exec(
+		"INSERT INTO links (id, tracking_id, target_url, created_at) VALUES ($1, $2, $3, $4)",
+		linkID, trackingID, targetURL, createdAt,
+	)
+	return err
+}
+
+func (s *postgresStore) GetLink(linkID string) (string, string, error) {
+	var trackingID, targetURL string
+	err := s.db.QueryRow("SELECT tracking_id, target_url FROM links WHERE id = $1", linkID).Scan(&trackingID, &targetURL)
+	if err == sql.ErrNoRows {
+		return "", "", errNotFound
+	} else if err != nil {
+		return "", "", err
+	}
+	return trackingID, targetURL, nil
+}
+
+func (s *postgresStore) GetTracking(trackingID string, realOnly bool) (TrackingData, error) {
+	var data TrackingData
+	var openedAt sql.NullTime
+
+	err := s.db.QueryRow(
+		"SELECT id, owner_id, email, subject, created_at, opened_at, ip_address, user_agent FROM tracking WHERE id = $1",
+		trackingID,
+	).Scan(&data.ID, &data.OwnerID, &data.Email, &data.Subject, &data.CreatedAt, &openedAt, &data.IPAddress, &data.UserAgent)
+	if err == sql.ErrNoRows {
+		return data, errNotFound
+	} else if err != nil {
+		return data, err
+	}
+
+	if openedAt.Valid {
+		data.OpenedAt = &openedAt.Time
+	}
+
+	clicks, err := s.listClicks(trackingID)
+	if err != nil {
+		return data, err
+	}
+	data.Clicks = clicks
+
+	openStats, err := s.getOpenStats(trackingID, realOnly)
+	if err != nil {
+		return data, err
+	}
+	data.OpenStats = openStats
+
+	return data, nil
+}
+
+func (s *postgresStore) ListTrackings(ownerID string) ([]TrackingData, error) {
+	rows, err := s.db.Query(
+		"SELECT id, owner_id, email, subject, created_at, opened_at, ip_address, user_agent FROM tracking WHERE owner_id = $1 ORDER BY created_at DESC",
+		ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trackings []TrackingData
+	for rows.Next() {
+		var data TrackingData
+		var openedAt sql.NullTime
+		if err := rows.Scan(&data.ID, &data.OwnerID, &data.Email, &data.Subject, &data.CreatedAt, &openedAt, &data.IPAddress, &data.UserAgent); err != nil {
+			return nil, err
+		}
+		if openedAt.Valid {
+			data.OpenedAt = &openedAt.Time
+		}
+		trackings = append(trackings, data)
+	}
+
+	return trackings, rows.Err()
+}
+
+func (s *postgresStore) CreateUser(user User) error {
+	_, err := s.db.Exec(
+		"INSERT INTO users (id, email, password_hash, activated, activation_token, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		user.ID, user.Email, user.PasswordHash, user.Activated, user.ActivationToken, user.CreatedAt,
+	)
+	return err
+}
+
+func (s *postgresStore) GetUserByEmail(email string) (User, error) {
+	return s.scanUser(s.db.QueryRow(
+		"SELECT id, email, password_hash, activated, activation_token, created_at FROM users WHERE email = $1",
+		email,
+	))
+}
+
+func (s *postgresStore) GetUserByID(userID string) (User, error) {
+	return s.scanUser(s.db.QueryRow(
+		"SELECT id, email, password_hash, activated, activation_token, created_at FROM users WHERE id = $1",
+		userID,
+	))
+}
+
+func (s *postgresStore) ActivateUser(activationToken string) error {
+	result, err := s.db.Exec(
+		"UPDATE users SET activated = TRUE, activation_token = NULL WHERE activation_token = $1 AND activated = FALSE",
+		activationToken,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+func (s *postgresStore) CreateAPIToken(token APIToken) error {
+	_, err := s.db.Exec(
+		"INSERT INTO api_tokens (token, user_id, created_at) VALUES ($1, $2, $3)",
+		token.Token, token.UserID, token.CreatedAt,
+	)
+	return err
+}
+
+func (s *postgresStore) GetUserByAPIToken(token string) (User, error) {
+	return s.scanUser(s.db.QueryRow(
+		`SELECT u.id, u.email, u.password_hash, u.activated, u.activation_token, u.created_at
+		 FROM users u JOIN api_tokens t ON t.user_id = u.id WHERE t.token = $1`,
+		token,
+	))
+}
+
+func (s *postgresStore) scanUser(row *sql.Row) (User, error) {
+	var user User
+	var activationToken sql.NullString
+
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Activated, &activationToken, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return user, errNotFound
+	} else if err != nil {
+		return user, err
+	}
+
+	user.ActivationToken = activationToken.String
+
+	return user, nil
+}
+
+func (s *postgresStore) listClicks(trackingID string) ([]LinkEvent, error) {
+	rows, err := s.db.Query(
+		"SELECT tracking_id, target_url, created_at, ip_address, user_agent, referrer FROM link_events WHERE tracking_id = $1 ORDER BY created_at ASC",
+		trackingID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []LinkEvent
+	for rows.Next() {
+		var e LinkEvent
+		if err := rows.Scan(&e.TrackingID, &e.TargetURL, &e.CreatedAt, &e.IPAddress, &e.UserAgent, &e.Referrer); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (s *postgresStore) DuePendingNotifications(now time.Time) ([]Notification, error) {
+	rows, err := s.db.Query(
+		`SELECT id, tracking_id, kind, target, payload, attempts, next_attempt_at, created_at
+		 FROM notifications WHERE status = 'pending' AND next_attempt_at <= $1 ORDER BY created_at ASC LIMIT 50`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.TrackingID, &n.Kind, &n.Target, &n.Payload, &n.Attempts, &n.NextAttemptAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (s *postgresStore) MarkNotificationSent(id int64) error {
+	_, err := s.db.Exec("UPDATE notifications SET status = 'sent' WHERE id = $1", id)
+	return err
+}
+
+func (s *postgresStore) MarkNotificationFailed(id int64, attempts int, nextAttemptAt time.Time) error {
+	status := "pending"
+	if attempts >= maxNotificationAttempts {
+		status = "dead"
+	}
+
+	_, err := s.db.Exec(
+		"UPDATE notifications SET status = $1, attempts = $2, next_attempt_at = $3 WHERE id = $4",
+		status, attempts, nextAttemptAt, id,
+	)
+	return err
+}
+
+func (s *postgresStore) getOpenStats(trackingID string, realOnly bool) (*OpenStats, error) {
+	rows, err := s.db.Query(
+		"SELECT tracking_id, created_at, ip_address, user_agent, country, referer, is_prefetch FROM open_events WHERE tracking_id = $1 ORDER BY created_at ASC",
+		trackingID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return collectOpenStats(rows, realOnly)
+}